@@ -34,14 +34,16 @@ type NearbyRequest struct {
 }
 
 const (
-	GeoSetKey    = "driver_locations" // Geospatial index for driver locations
-	DriverPrefix = "driver:"          // Prefix for driver info hash
-	ActiveSetKey = "active_drivers"   // Set for tracking active drivers
+	GeoSetKey            = "driver_locations" // Geospatial index for driver locations (sharded, see geo_shard.go)
+	DriverPrefix         = "driver:"          // Prefix for driver info hash
+	ActiveSetKey         = "active_drivers"   // Set for tracking active drivers
+	DriverShardKeyPrefix = "driver_shard:"    // Prefix for the index of which geo shard a driver lives in
 )
 
 func (app *App) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	_, err := app.RedisCache.Ping(ctx).Result()
+	app.RedisBreaker.RecordResult(err)
 
 	if err != nil {
 		respondWithError(w, http.StatusServiceUnavailable, "Redis connection failed")
@@ -59,47 +61,17 @@ func (app *App) UpdateDriverLocation(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
-
-	ctx := context.Background()
-
-	driver := Driver{
-		ID:        update.DriverID,
-		Latitude:  update.Latitude,
-		Longitude: update.Longitude,
-		IsActive:  update.IsActive,
-		LastPing:  time.Now(),
-	}
-
-	pipe := app.RedisCache.Pipeline()
-
-	driverKey := DriverPrefix + update.DriverID
-	driverData, err := json.Marshal(driver)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to serialize driver data")
+	if update.DriverID == "" {
+		respondWithError(w, http.StatusBadRequest, "driverId is required")
 		return
 	}
 
-	pipe.Set(ctx, driverKey, driverData, 1*time.Minute)
-
-	pipe.GeoAdd(ctx, GeoSetKey, &redis.GeoLocation{
-		Name:      update.DriverID,
-		Longitude: update.Longitude,
-		Latitude:  update.Latitude,
-	})
-
-	if update.IsActive {
-		pipe.SAdd(ctx, ActiveSetKey, update.DriverID)
-	} else {
-		pipe.SRem(ctx, ActiveSetKey, update.DriverID)
-	}
-
-	_, err = pipe.Exec(ctx)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Redis operation failed: %v", err))
+	if !app.UpdateQueue.Enqueue(update) {
+		respondWithError(w, http.StatusServiceUnavailable, "Update queue is full, please retry")
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Driver location updated successfully"})
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Driver location update queued"})
 }
 
 func (app *App) FindNearbyDrivers(w http.ResponseWriter, r *http.Request) {
@@ -120,24 +92,8 @@ func (app *App) FindNearbyDrivers(w http.ResponseWriter, r *http.Request) {
 
 	ctx := context.Background()
 
-	geoOptions := &redis.GeoRadiusQuery{
-		Radius:      req.Radius,
-		Unit:        "km",
-		WithCoord:   true,
-		WithDist:    true,
-		WithGeoHash: false,
-		Count:       req.Limit,
-		Sort:        "ASC",
-	}
-
-	locations, err := app.RedisCache.GeoRadius(
-		ctx,
-		GeoSetKey,
-		req.Longitude,
-		req.Latitude,
-		geoOptions,
-	).Result()
-
+	locations, err := app.geoSearchNearby(ctx, req.Latitude, req.Longitude, req.Radius, req.Limit)
+	app.RedisBreaker.RecordResult(err)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Geospatial query failed: %v", err))
 		return
@@ -205,12 +161,15 @@ func (app *App) GetDriver(w http.ResponseWriter, r *http.Request) {
 	driverData, err := app.RedisCache.Get(ctx, driverKey).Result()
 
 	if errors.Is(err, redis.Nil) {
+		app.RedisBreaker.RecordResult(nil) // a miss is not an infrastructure failure
 		respondWithError(w, http.StatusNotFound, "Driver not found")
 		return
 	} else if err != nil {
+		app.RedisBreaker.RecordResult(err)
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Redis operation failed: %v", err))
 		return
 	}
+	app.RedisBreaker.RecordResult(nil)
 
 	var driver Driver
 	if err := json.Unmarshal([]byte(driverData), &driver); err != nil {
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreaker guards the Redis calls a handler makes. It opens after
+// FailureThreshold consecutive failures, rejects calls while open, and
+// half-opens after CoolDown to probe recovery. The failure count is reset
+// only by a successful call; it is not bounded by any time window.
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	FailureThreshold int
+	CoolDown         time.Duration
+
+	state         circuitState
+	failures      int
+	openedAt      time.Time
+	probeInFlight bool
+}
+
+func NewCircuitBreaker(failureThreshold int, coolDown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{FailureThreshold: failureThreshold, CoolDown: coolDown}
+}
+
+// Allow reports whether a call should proceed, transitioning open->half-open
+// once the cool-down has elapsed.
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitClosed:
+		return true
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.CoolDown {
+			circuitBreakerEventsTotal.WithLabelValues("rejected").Inc()
+			return false
+		}
+		cb.state = circuitHalfOpen
+		cb.probeInFlight = true
+		circuitBreakerEventsTotal.WithLabelValues("half_opened").Inc()
+		return true
+	case circuitHalfOpen:
+		// Only let one probe through at a time; reject the rest until it resolves.
+		if cb.probeInFlight {
+			circuitBreakerEventsTotal.WithLabelValues("rejected").Inc()
+			return false
+		}
+		cb.probeInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Done releases the current half-open probe slot if the request Allow()
+// admitted returns without ever calling RecordResult - e.g. it failed
+// validation, got rate-limited, or queued its Redis work asynchronously
+// (UpdateDriverLocation's 202 path). Without this, that single probe
+// request holds probeInFlight forever and the breaker stays wedged
+// half-open, rejecting every request until the process restarts. It's a
+// no-op if RecordResult already resolved the probe.
+func (cb *CircuitBreaker) Done() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probeInFlight = false
+}
+
+// RecordResult updates breaker state based on the outcome of a Redis call
+// made while Allow() permitted it.
+func (cb *CircuitBreaker) RecordResult(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err == nil {
+		cb.failures = 0
+		cb.probeInFlight = false
+		if cb.state != circuitClosed {
+			cb.state = circuitClosed
+			circuitBreakerEventsTotal.WithLabelValues("closed").Inc()
+		}
+		return
+	}
+
+	cb.probeInFlight = false
+
+	if cb.state == circuitHalfOpen {
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+		circuitBreakerEventsTotal.WithLabelValues("opened").Inc()
+		return
+	}
+
+	cb.failures++
+	if cb.failures >= cb.FailureThreshold && cb.state == circuitClosed {
+		cb.state = circuitOpen
+		cb.openedAt = time.Now()
+		circuitBreakerEventsTotal.WithLabelValues("opened").Inc()
+	}
+}
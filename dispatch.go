@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ReservationLockPrefix = "reservation:"      // SET NX EX hold, value is the reserving rider's ID
+	ReservationDataPrefix = "reservation_data:" // hash: rider, expiresAt, pickupLat, pickupLng
+
+	defaultReservationHoldSeconds = 30
+	defaultReservationCandidates  = 10
+	defaultReservationRadiusKm    = 5
+)
+
+// claimFirstUnlockedDriver tries reservation keys in distance order and
+// claims the first one that isn't already held, so two concurrent reserve
+// calls can never both win the same driver. Each claim is a single-key
+// SET NX EX, so unlike a multi-key Lua script this works unmodified under
+// Redis Cluster, where lockKeys can hash to different slots.
+func claimFirstUnlockedDriver(ctx context.Context, rdb RedisBackend, lockKeys []string, holdSeconds int, riderID string) (string, error) {
+	for _, key := range lockKeys {
+		locked, err := rdb.SetNX(ctx, key, riderID, time.Duration(holdSeconds)*time.Second).Result()
+		if err != nil {
+			return "", err
+		}
+		if locked {
+			return key, nil
+		}
+	}
+	return "", redis.Nil
+}
+
+type ReserveRequest struct {
+	RiderID     string  `json:"riderId"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Radius      float64 `json:"radius"`      // candidate search radius, in kilometers
+	HoldSeconds int     `json:"holdSeconds"` // how long the reservation lock is held for
+}
+
+type ReservationRequest struct {
+	DriverID string `json:"driverId"`
+	RiderID  string `json:"riderId"`
+}
+
+// ReserveDriver finds the closest active, unreserved driver to a pickup
+// point and locks them for the requesting rider.
+func (app *App) ReserveDriver(w http.ResponseWriter, r *http.Request) {
+	var req ReserveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.RiderID == "" {
+		respondWithError(w, http.StatusBadRequest, "riderId is required")
+		return
+	}
+	if req.Radius == 0 {
+		req.Radius = defaultReservationRadiusKm
+	}
+	if req.HoldSeconds == 0 {
+		req.HoldSeconds = defaultReservationHoldSeconds
+	}
+
+	ctx := context.Background()
+
+	locations, err := app.geoSearchNearby(ctx, req.Latitude, req.Longitude, req.Radius, defaultReservationCandidates)
+	app.RedisBreaker.RecordResult(err)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Geospatial query failed: %v", err))
+		return
+	}
+	if len(locations) == 0 {
+		respondWithError(w, http.StatusConflict, "No drivers nearby")
+		return
+	}
+
+	candidateIDs := make([]interface{}, len(locations))
+	for i, loc := range locations {
+		candidateIDs[i] = loc.Name
+	}
+
+	activeFlags, err := app.RedisCache.SMIsMember(ctx, ActiveSetKey, candidateIDs...).Result()
+	app.RedisBreaker.RecordResult(err)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Redis operation failed: %v", err))
+		return
+	}
+
+	lockKeys := make([]string, 0, len(locations))
+	for i, loc := range locations {
+		if i < len(activeFlags) && activeFlags[i] {
+			lockKeys = append(lockKeys, ReservationLockPrefix+loc.Name)
+		}
+	}
+	if len(lockKeys) == 0 {
+		respondWithError(w, http.StatusConflict, "No active drivers nearby")
+		return
+	}
+
+	winningKey, err := claimFirstUnlockedDriver(ctx, app.RedisCache, lockKeys, req.HoldSeconds, req.RiderID)
+	if errors.Is(err, redis.Nil) {
+		respondWithError(w, http.StatusConflict, "All nearby drivers are already reserved")
+		return
+	}
+	app.RedisBreaker.RecordResult(err)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Reservation claim failed: %v", err))
+		return
+	}
+
+	driverID := strings.TrimPrefix(winningKey, ReservationLockPrefix)
+
+	expiresAt := time.Now().Add(time.Duration(req.HoldSeconds) * time.Second)
+	holdTTL := time.Duration(req.HoldSeconds) * time.Second
+
+	pipe := app.RedisCache.Pipeline()
+	pipe.HSet(ctx, ReservationDataPrefix+driverID, map[string]interface{}{
+		"rider":     req.RiderID,
+		"expiresAt": expiresAt.Format(time.RFC3339),
+		"pickupLat": req.Latitude,
+		"pickupLng": req.Longitude,
+	})
+	pipe.Expire(ctx, ReservationDataPrefix+driverID, holdTTL)
+	_, err = pipe.Exec(ctx)
+	app.RedisBreaker.RecordResult(err)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to persist reservation: %v", err))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"driverId":  driverID,
+		"expiresAt": expiresAt,
+	})
+}
+
+// ConfirmReservation finalizes a reservation: the driver is committed to
+// the trip and pulled out of the dispatch pool.
+func (app *App) ConfirmReservation(w http.ResponseWriter, r *http.Request) {
+	app.resolveReservation(w, r, func(ctx context.Context, driverID string) error {
+		pipe := app.RedisCache.Pipeline()
+		pipe.Del(ctx, ReservationLockPrefix+driverID)
+		pipe.Del(ctx, ReservationDataPrefix+driverID)
+		pipe.SRem(ctx, ActiveSetKey, driverID)
+		_, err := pipe.Exec(ctx)
+		return err
+	}, "Reservation confirmed")
+}
+
+// ReleaseReservation frees a reservation early so the driver is immediately
+// reservable again, without waiting for the hold to expire.
+func (app *App) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	app.resolveReservation(w, r, func(ctx context.Context, driverID string) error {
+		pipe := app.RedisCache.Pipeline()
+		pipe.Del(ctx, ReservationLockPrefix+driverID)
+		pipe.Del(ctx, ReservationDataPrefix+driverID)
+		_, err := pipe.Exec(ctx)
+		return err
+	}, "Reservation released")
+}
+
+// resolveReservation validates that the caller still holds driverID's
+// reservation, then runs apply to finalize or free it.
+func (app *App) resolveReservation(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, driverID string) error, successMessage string) {
+	var req ReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.DriverID == "" || req.RiderID == "" {
+		respondWithError(w, http.StatusBadRequest, "driverId and riderId are required")
+		return
+	}
+
+	ctx := context.Background()
+
+	holder, err := app.RedisCache.Get(ctx, ReservationLockPrefix+req.DriverID).Result()
+	if errors.Is(err, redis.Nil) {
+		respondWithError(w, http.StatusNotFound, "Reservation not found or already expired")
+		return
+	}
+	app.RedisBreaker.RecordResult(err)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Redis operation failed: %v", err))
+		return
+	}
+	if holder != req.RiderID {
+		respondWithError(w, http.StatusForbidden, "Reservation is held by a different rider")
+		return
+	}
+
+	if err := apply(ctx, req.DriverID); err != nil {
+		app.RedisBreaker.RecordResult(err)
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Redis operation failed: %v", err))
+		return
+	}
+	app.RedisBreaker.RecordResult(nil)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": successMessage})
+}
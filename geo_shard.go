@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Geo commands (GEOADD/GEOSEARCH) operate on a single key, which in Cluster
+// mode means a single driver_locations set can only ever live on one slot.
+// To spread drivers across the cluster we shard the geo index by a coarse
+// geohash prefix of each driver's coordinates, hash-tagging the key so all
+// members of one shard land on the same slot. Writes go to the shard owning
+// the driver; reads fan out to every shard whose tile can intersect the
+// query circle and merge the results.
+const (
+	geohashAlphabet   = "0123456789bcdefghjkmnpqrstuvwxyz"
+	geoShardPrecision = 3 // ~156km x 156km tiles at the equator
+)
+
+// geohashEncode computes the standard base32 geohash of (lat, lon),
+// truncated to precision characters.
+func geohashEncode(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	hash := make([]byte, 0, precision)
+	bit, ch := 0, 0
+	evenBit := true
+
+	for len(hash) < precision {
+		if evenBit {
+			mid := (lonRange[0] + lonRange[1]) / 2
+			if lon >= mid {
+				ch = ch<<1 | 1
+				lonRange[0] = mid
+			} else {
+				ch = ch << 1
+				lonRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				latRange[0] = mid
+			} else {
+				ch = ch << 1
+				latRange[1] = mid
+			}
+		}
+		evenBit = !evenBit
+
+		bit++
+		if bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return string(hash)
+}
+
+// geoShardKeyForPrefix returns the hash-tagged driver_locations key for a
+// given geohash prefix, e.g. "{geo:9q8}:driver_locations".
+func geoShardKeyForPrefix(prefix string) string {
+	return fmt.Sprintf("{geo:%s}:%s", prefix, GeoSetKey)
+}
+
+// geoShardKey returns the shard key that owns a driver at (lat, lon).
+func geoShardKey(lat, lon float64) string {
+	return geoShardKeyForPrefix(geohashEncode(lat, lon, geoShardPrecision))
+}
+
+// geoShardKeysForCircle returns every shard key whose geohash tile can
+// intersect a circle of radiusKm centered at (lat, lon). It over-includes
+// rather than under-includes; a shard with no qualifying members simply
+// returns an empty GeoSearch result.
+func geoShardKeysForCircle(lat, lon, radiusKm float64) []string {
+	latDelta := radiusKm / 111.0
+
+	cos := math.Cos(lat * math.Pi / 180)
+	if cos < 0.01 {
+		cos = 0.01 // guard against the cosine collapsing near the poles
+	}
+	lonDelta := radiusKm / (111.320 * cos)
+
+	step := latDelta / 2
+	if step <= 0 {
+		step = 0.01
+	}
+	// Never sample more coarsely than one tile, or a whole tile could fall between samples.
+	if tileDeg := 180 / math.Exp2(float64(geoShardPrecision*5/2)); step > tileDeg {
+		step = tileDeg
+	}
+
+	minLat, maxLat := lat-latDelta, lat+latDelta
+	minLon, maxLon := lon-lonDelta, lon+lonDelta
+
+	seen := make(map[string]struct{})
+	keys := make([]string, 0, 4)
+
+	for la := minLat; la < maxLat+step; la += step {
+		for lo := minLon; lo < maxLon+step; lo += step {
+			prefix := geohashEncode(la, math.Mod(lo+540, 360)-180, geoShardPrecision) // wrap across the antimeridian
+			if _, ok := seen[prefix]; ok {
+				continue
+			}
+			seen[prefix] = struct{}{}
+			keys = append(keys, geoShardKeyForPrefix(prefix))
+		}
+	}
+
+	return keys
+}
+
+// geoSearchNearby fans a GeoSearch query out to every shard that can
+// intersect the query circle, then merges and re-sorts the results by
+// distance before applying limit.
+func (app *App) geoSearchNearby(ctx context.Context, lat, lon, radiusKm float64, limit int) ([]redis.GeoLocation, error) {
+	query := &redis.GeoSearchLocationQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{
+			Longitude:  lon,
+			Latitude:   lat,
+			Radius:     radiusKm,
+			RadiusUnit: "km",
+			Sort:       "ASC",
+			Count:      limit,
+		},
+		WithCoord: true,
+		WithDist:  true,
+	}
+
+	var merged []redis.GeoLocation
+	for _, shardKey := range geoShardKeysForCircle(lat, lon, radiusKm) {
+		locations, err := app.RedisCache.GeoSearchLocation(ctx, shardKey, query).Result()
+		if err != nil {
+			return nil, fmt.Errorf("shard %s: %w", shardKey, err)
+		}
+		merged = append(merged, locations...)
+	}
+
+	// A driver that just crossed shards can briefly have a stale entry in
+	// its old shard alongside the current one; when a query circle spans
+	// both, keep only the closest hit for each driver.
+	merged = dedupeClosestByName(merged)
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Dist < merged[j].Dist })
+
+	if limit > 0 && len(merged) > limit {
+		merged = merged[:limit]
+	}
+
+	return merged, nil
+}
+
+// dedupeClosestByName keeps, for each driver name, only the location with
+// the smallest Dist, preserving the relative order of first occurrence.
+func dedupeClosestByName(locations []redis.GeoLocation) []redis.GeoLocation {
+	best := make(map[string]int, len(locations))
+	deduped := make([]redis.GeoLocation, 0, len(locations))
+
+	for _, loc := range locations {
+		if i, ok := best[loc.Name]; ok {
+			if loc.Dist < deduped[i].Dist {
+				deduped[i] = loc
+			}
+			continue
+		}
+		best[loc.Name] = len(deduped)
+		deduped = append(deduped, loc)
+	}
+
+	return deduped
+}
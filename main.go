@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/gorilla/mux"
-	"github.com/redis/go-redis/v9"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
 	"net/http"
 	"os"
@@ -16,17 +16,25 @@ import (
 
 type App struct {
 	Router     *mux.Router
-	RedisCache *redis.Client
+	RedisCache RedisBackend
+
+	DriverUpdateLimiter *RateLimiter
+	NearbyLimiter       *RateLimiter
+	RedisBreaker        *CircuitBreaker
+	UpdateQueue         UpdateQueue
 }
 
-func (app *App) Initialize(redisAddr string) error {
-	app.RedisCache = redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: "",
-	})
+func (app *App) Initialize(cfg RedisConfig) error {
+	backend, err := newRedisBackend(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to build Redis backend: %w", err)
+	}
+	app.RedisCache = backend
+	app.setupMiddleware()
+	app.setupQueue()
 
 	ctx := context.Background()
-	_, err := app.RedisCache.Ping(ctx).Result()
+	_, err = app.RedisCache.Ping(ctx).Result()
 
 	if err != nil {
 		return fmt.Errorf("failed to connect to Redis: %w", err)
@@ -54,30 +62,62 @@ func (app *App) setupExpirationListener() {
 		ch := pubsub.Channel()
 		for msg := range ch {
 			key := msg.Payload
-			if strings.HasPrefix(key, DriverPrefix) {
-				driverID := strings.TrimPrefix(key, DriverPrefix)
+			switch {
+			case strings.HasPrefix(key, DriverPrefix):
+				app.cleanupExpiredDriver(strings.TrimPrefix(key, DriverPrefix))
+			case strings.HasPrefix(key, ReservationLockPrefix):
+				app.cleanupExpiredReservation(strings.TrimPrefix(key, ReservationLockPrefix))
+			}
+		}
+	}()
+}
+
+func (app *App) cleanupExpiredDriver(driverID string) {
+	ctx := context.Background()
 
-				ctx := context.Background()
-				pipe := app.RedisCache.Pipeline()
+	// The driver's own shard-index entry shares a TTL with driver:<id> and
+	// usually expires in the same tick, but may already be gone; fall back
+	// to the unsharded key name, which is at worst a harmless no-op ZREM.
+	shardKey, err := app.RedisCache.Get(ctx, DriverShardKeyPrefix+driverID).Result()
+	if err != nil {
+		shardKey = GeoSetKey
+	}
 
-				pipe.ZRem(ctx, GeoSetKey, driverID)
+	pipe := app.RedisCache.Pipeline()
+	pipe.ZRem(ctx, shardKey, driverID)
+	pipe.SRem(ctx, ActiveSetKey, driverID)
+	pipe.Del(ctx, DriverShardKeyPrefix+driverID)
 
-				pipe.SRem(ctx, ActiveSetKey, driverID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("Error cleaning up expired driver %s: %v", driverID, err)
+	}
 
-				_, err := pipe.Exec(ctx)
-				if err != nil {
-					log.Printf("Error cleaning up expired driver %s: %v", driverID, err)
-				}
-			}
-		}
-	}()
+	app.publishDriverUpdate(ctx, DriverUpdateEvent{DriverID: driverID, Left: true})
+}
+
+func (app *App) cleanupExpiredReservation(driverID string) {
+	ctx := context.Background()
+
+	if err := app.RedisCache.Del(ctx, ReservationDataPrefix+driverID).Err(); err != nil {
+		log.Printf("Error cleaning up stale reservation for driver %s: %v", driverID, err)
+		return
+	}
+
+	log.Printf("Released stale reservation hold for driver %s", driverID)
 }
 
 func (app *App) setupRoutes() {
-	app.Router.HandleFunc("/api/location/health", app.HealthCheck).Methods("GET")
-	app.Router.HandleFunc("/api/location/drivers/update", app.UpdateDriverLocation).Methods("POST")
-	app.Router.HandleFunc("/api/location/drivers/nearby", app.FindNearbyDrivers).Methods("POST")
-	app.Router.HandleFunc("/api/location/drivers/{id}", app.GetDriver).Methods("GET")
+	app.Router.HandleFunc("/api/location/health", app.withCircuitBreaker(app.HealthCheck)).Methods("GET")
+	app.Router.HandleFunc("/api/location/drivers/update",
+		app.withCircuitBreaker(app.withDriverRateLimit(app.UpdateDriverLocation))).Methods("POST")
+	app.Router.HandleFunc("/api/location/drivers/nearby",
+		app.withCircuitBreaker(app.withIPRateLimit(app.FindNearbyDrivers))).Methods("POST")
+	app.Router.HandleFunc("/api/location/drivers/nearby/stream", app.withCircuitBreaker(app.NearbyDriversStream)).Methods("GET")
+	app.Router.HandleFunc("/api/location/drivers/{id}", app.withCircuitBreaker(app.GetDriver)).Methods("GET")
+	app.Router.HandleFunc("/api/location/dispatch/reserve", app.withCircuitBreaker(app.ReserveDriver)).Methods("POST")
+	app.Router.HandleFunc("/api/location/dispatch/confirm", app.withCircuitBreaker(app.ConfirmReservation)).Methods("POST")
+	app.Router.HandleFunc("/api/location/dispatch/release", app.withCircuitBreaker(app.ReleaseReservation)).Methods("POST")
+	app.Router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 }
 
 func (app *App) Run(addr string) {
@@ -105,32 +145,32 @@ func (app *App) Run(addr string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := app.RedisCache.Close(); err != nil {
-		log.Printf("Error closing Redis connection: %v", err)
-	}
-
 	log.Println("Shutting down server...")
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	log.Println("Draining update queue...")
+	app.UpdateQueue.Shutdown(ctx)
+
+	if err := app.RedisCache.Close(); err != nil {
+		log.Printf("Error closing Redis connection: %v", err)
+	}
+
 	log.Println("Server gracefully stopped")
 }
 
 func main() {
 	app := App{}
 
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "localhost:6379"
-	}
+	redisCfg := loadRedisConfigFromEnv("localhost:6379")
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
-	if err := app.Initialize(redisAddr); err != nil {
+	if err := app.Initialize(redisCfg); err != nil {
 		log.Fatalf("Failed to initialize app: %v", err)
 	}
 
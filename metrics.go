@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	rateLimitEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "location_service_rate_limit_events_total",
+		Help: "Count of rate limiter decisions, labeled by limiter and outcome (allowed/denied).",
+	}, []string{"limiter", "outcome"})
+
+	circuitBreakerEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "location_service_circuit_breaker_events_total",
+		Help: "Count of circuit breaker transitions and short-circuits (opened/closed/half_opened/rejected).",
+	}, []string{"event"})
+
+	queueDepthGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "location_service_update_queue_depth",
+		Help: "Number of location updates currently buffered in the write-behind queue.",
+	})
+
+	queueBatchSizeHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "location_service_update_queue_batch_size",
+		Help:    "Size of batches flushed from the write-behind queue to Redis.",
+		Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250},
+	})
+
+	queueDropsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "location_service_update_queue_drops_total",
+		Help: "Count of location updates dropped because the write-behind queue was full or unavailable.",
+	})
+)
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	driverUpdateRateLimitPrefix = "ratelimit:driver:"
+	nearbyRateLimitPrefix       = "ratelimit:ip:"
+)
+
+// setupMiddleware builds the rate limiters and circuit breaker from env
+// vars, with sensible defaults for local development.
+func (app *App) setupMiddleware() {
+	updateLimit := envInt("RATE_LIMIT_UPDATE_PER_WINDOW", 120)
+	nearbyLimit := envInt("RATE_LIMIT_NEARBY_PER_WINDOW", 60)
+	window := envDuration("RATE_LIMIT_WINDOW", time.Minute)
+
+	app.DriverUpdateLimiter = NewRateLimiter(app.RedisCache, "driver_update", driverUpdateRateLimitPrefix, int64(updateLimit), window)
+	app.NearbyLimiter = NewRateLimiter(app.RedisCache, "nearby_search", nearbyRateLimitPrefix, int64(nearbyLimit), window)
+
+	failureThreshold := envInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5)
+	coolDown := envDuration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second)
+	app.RedisBreaker = NewCircuitBreaker(failureThreshold, coolDown)
+}
+
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
+
+// withCircuitBreaker short-circuits the handler with 503 while the shared
+// Redis circuit breaker is open.
+func (app *App) withCircuitBreaker(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !app.RedisBreaker.Allow() {
+			respondWithError(w, http.StatusServiceUnavailable, "Service temporarily unavailable, please retry shortly")
+			return
+		}
+		defer app.RedisBreaker.Done()
+		next(w, r)
+	}
+}
+
+// withDriverRateLimit enforces a per-driver token budget on requests whose
+// JSON body carries a driverId, without consuming the body for the handler.
+func (app *App) withDriverRateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var peek struct {
+			DriverID string `json:"driverId"`
+		}
+		if err := json.Unmarshal(body, &peek); err != nil || peek.DriverID == "" {
+			// Malformed payloads are the handler's problem to report.
+			next(w, r)
+			return
+		}
+
+		allowed, err := app.DriverUpdateLimiter.Allow(r.Context(), peek.DriverID)
+		if err != nil {
+			log.Printf("Rate limiter error, failing open: %v", err)
+			next(w, r)
+			return
+		}
+		if !allowed {
+			respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded for this driver")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withIPRateLimit enforces a per-client-IP token budget.
+func (app *App) withIPRateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed, err := app.NearbyLimiter.Allow(r.Context(), clientIP(r))
+		if err != nil {
+			log.Printf("Rate limiter error, failing open: %v", err)
+			next(w, r)
+			return
+		}
+		if !allowed {
+			respondWithError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	driverUpdateQueueKey = "driver_updates_queue" // durable Redis-backed queue, when QUEUE_TYPE=redis
+
+	defaultQueueCapacity     = 1000
+	defaultQueueBatchSize    = 100
+	defaultQueueFlushPeriod  = 50 * time.Millisecond
+	defaultQueueWorkerCount  = 4
+	defaultQueueShutdownWait = 5 * time.Second
+)
+
+// UpdateQueue decouples UpdateDriverLocation from the Redis round-trip: the
+// handler enqueues and returns immediately, while a worker pool drains the
+// queue in pipelined batches.
+type UpdateQueue interface {
+	Enqueue(update LocationUpdate) bool
+	Shutdown(ctx context.Context)
+}
+
+// setupQueue builds the configured UpdateQueue implementation from env vars.
+func (app *App) setupQueue() {
+	capacity := envInt("QUEUE_CAPACITY", defaultQueueCapacity)
+	batchSize := envInt("QUEUE_BATCH_SIZE", defaultQueueBatchSize)
+	flushPeriod := envDuration("QUEUE_FLUSH_INTERVAL", defaultQueueFlushPeriod)
+	workers := envInt("QUEUE_WORKERS", defaultQueueWorkerCount)
+
+	queueType := os.Getenv("QUEUE_TYPE")
+	if queueType == "redis" {
+		app.UpdateQueue = NewRedisUpdateQueue(app, driverUpdateQueueKey, batchSize, workers)
+		log.Println("Using durable Redis-backed update queue")
+		return
+	}
+
+	app.UpdateQueue = NewMemoryUpdateQueue(app, capacity, batchSize, workers, flushPeriod)
+	log.Println("Using in-process update queue")
+}
+
+// flushLocationUpdates writes a batch of updates in one pipelined Redis
+// round-trip, preceded by one pipelined lookup of each driver's previous
+// shard, and publishes a pub/sub event per update once it lands.
+func (app *App) flushLocationUpdates(batch []LocationUpdate) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+
+	// driver_shard:<id> keys aren't hash-tagged, so a cross-key MGET would hit
+	// CROSSSLOT as soon as a batch spans drivers on different Cluster slots.
+	// Pipelining single-key GETs keeps each lookup on its own slot.
+	lookupPipe := app.RedisCache.Pipeline()
+	shardGets := make([]*redis.StringCmd, len(batch))
+	for i, update := range batch {
+		shardGets[i] = lookupPipe.Get(ctx, DriverShardKeyPrefix+update.DriverID)
+	}
+	_, err := lookupPipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		app.RedisBreaker.RecordResult(err)
+		log.Printf("Failed to look up previous geo shards, stale shard entries may linger: %v", err)
+	} else {
+		app.RedisBreaker.RecordResult(nil)
+	}
+
+	prevShardKeys := make([]string, len(batch))
+	for i, cmd := range shardGets {
+		if v, err := cmd.Result(); err == nil {
+			prevShardKeys[i] = v
+		}
+	}
+
+	pipe := app.RedisCache.Pipeline()
+
+	queued := make([]LocationUpdate, 0, len(batch))
+	for i, update := range batch {
+		driver := Driver{
+			ID:        update.DriverID,
+			Latitude:  update.Latitude,
+			Longitude: update.Longitude,
+			IsActive:  update.IsActive,
+			LastPing:  time.Now(),
+		}
+
+		driverData, err := json.Marshal(driver)
+		if err != nil {
+			log.Printf("Failed to serialize queued update for driver %s: %v", update.DriverID, err)
+			continue
+		}
+
+		shardKey := geoShardKey(update.Latitude, update.Longitude)
+
+		// A driver crossing a geohash tile boundary moves shards; ZREM the
+		// stale entry so it doesn't linger and get returned twice by
+		// geoSearchNearby when a query spans both shards.
+		if prevShardKey := prevShardKeys[i]; prevShardKey != "" && prevShardKey != shardKey {
+			pipe.ZRem(ctx, prevShardKey, update.DriverID)
+		}
+
+		pipe.Set(ctx, DriverPrefix+update.DriverID, driverData, 1*time.Minute)
+		// Remember which geo shard the driver landed in so the expiration
+		// listener can ZREM it from the right key later.
+		pipe.Set(ctx, DriverShardKeyPrefix+update.DriverID, shardKey, 1*time.Minute)
+		pipe.GeoAdd(ctx, shardKey, &redis.GeoLocation{
+			Name:      update.DriverID,
+			Longitude: update.Longitude,
+			Latitude:  update.Latitude,
+		})
+		if update.IsActive {
+			pipe.SAdd(ctx, ActiveSetKey, update.DriverID)
+		} else {
+			pipe.SRem(ctx, ActiveSetKey, update.DriverID)
+		}
+		queued = append(queued, update)
+	}
+
+	_, err = pipe.Exec(ctx)
+	app.RedisBreaker.RecordResult(err)
+	if err != nil {
+		log.Printf("Batched location update failed: %v", err)
+		return
+	}
+
+	for _, update := range queued {
+		app.publishDriverUpdate(ctx, DriverUpdateEvent{
+			DriverID:  update.DriverID,
+			Latitude:  update.Latitude,
+			Longitude: update.Longitude,
+			IsActive:  update.IsActive,
+		})
+	}
+}
+
+// MemoryUpdateQueue buffers updates in a channel and flushes them on a
+// timer or once a batch fills up, whichever comes first. Updates are lost
+// if the process crashes before a flush.
+type MemoryUpdateQueue struct {
+	app           *App
+	ch            chan LocationUpdate
+	batchSize     int
+	flushInterval time.Duration
+	wg            sync.WaitGroup
+}
+
+func NewMemoryUpdateQueue(app *App, capacity, batchSize, workers int, flushInterval time.Duration) *MemoryUpdateQueue {
+	q := &MemoryUpdateQueue{
+		app:           app,
+		ch:            make(chan LocationUpdate, capacity),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return q
+}
+
+func (q *MemoryUpdateQueue) Enqueue(update LocationUpdate) bool {
+	select {
+	case q.ch <- update:
+		queueDepthGauge.Set(float64(len(q.ch)))
+		return true
+	default:
+		queueDropsTotal.Inc()
+		return false
+	}
+}
+
+func (q *MemoryUpdateQueue) worker() {
+	defer q.wg.Done()
+
+	batch := make([]LocationUpdate, 0, q.batchSize)
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		q.app.flushLocationUpdates(batch)
+		queueBatchSizeHistogram.Observe(float64(len(batch)))
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case update, ok := <-q.ch:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, update)
+			if len(batch) >= q.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+		queueDepthGauge.Set(float64(len(q.ch)))
+	}
+}
+
+func (q *MemoryUpdateQueue) Shutdown(ctx context.Context) {
+	close(q.ch)
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("Timed out waiting for in-memory update queue to drain")
+	}
+}
+
+// RedisUpdateQueue pushes updates onto a Redis list so they survive process
+// crashes; a pool of BRPOP workers drains and batches them.
+type RedisUpdateQueue struct {
+	app       *App
+	listKey   string
+	batchSize int
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func NewRedisUpdateQueue(app *App, listKey string, batchSize, workers int) *RedisUpdateQueue {
+	q := &RedisUpdateQueue{app: app, listKey: listKey, batchSize: batchSize, stop: make(chan struct{})}
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+
+	return q
+}
+
+func (q *RedisUpdateQueue) Enqueue(update LocationUpdate) bool {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("Failed to marshal queued update: %v", err)
+		queueDropsTotal.Inc()
+		return false
+	}
+
+	ctx := context.Background()
+	err = q.app.RedisCache.LPush(ctx, q.listKey, payload).Err()
+	q.app.RedisBreaker.RecordResult(err)
+	if err != nil {
+		log.Printf("Failed to enqueue update to Redis: %v", err)
+		queueDropsTotal.Inc()
+		return false
+	}
+
+	if depth, err := q.app.RedisCache.LLen(ctx, q.listKey).Result(); err == nil {
+		queueDepthGauge.Set(float64(depth))
+	}
+	return true
+}
+
+func (q *RedisUpdateQueue) worker() {
+	defer q.wg.Done()
+	ctx := context.Background()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		result, err := q.app.RedisCache.BRPop(ctx, time.Second, q.listKey).Result()
+		if err != nil {
+			continue // timeout or transient error; loop back around to check q.stop
+		}
+		if len(result) < 2 {
+			continue
+		}
+
+		batch := make([]LocationUpdate, 0, q.batchSize)
+		if update, ok := decodeQueuedUpdate(result[1]); ok {
+			batch = append(batch, update)
+		}
+
+		// Opportunistically drain the rest of the backlog without blocking.
+		for len(batch) < q.batchSize {
+			raw, err := q.app.RedisCache.LPop(ctx, q.listKey).Result()
+			if err != nil {
+				break
+			}
+			if update, ok := decodeQueuedUpdate(raw); ok {
+				batch = append(batch, update)
+			}
+		}
+
+		q.app.flushLocationUpdates(batch)
+		queueBatchSizeHistogram.Observe(float64(len(batch)))
+
+		if depth, err := q.app.RedisCache.LLen(ctx, q.listKey).Result(); err == nil {
+			queueDepthGauge.Set(float64(depth))
+		}
+	}
+}
+
+func decodeQueuedUpdate(raw string) (LocationUpdate, bool) {
+	var update LocationUpdate
+	if err := json.Unmarshal([]byte(raw), &update); err != nil {
+		log.Printf("Dropping malformed queued update: %v", err)
+		return LocationUpdate{}, false
+	}
+	return update, true
+}
+
+func (q *RedisUpdateQueue) Shutdown(ctx context.Context) {
+	close(q.stop)
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("Timed out waiting for Redis update queue workers to stop")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RateLimiter is a fixed-window counter backed by Redis INCR+EXPIRE, so
+// limits are shared across replicas and survive process restarts.
+type RateLimiter struct {
+	backend RedisBackend
+	name    string
+	prefix  string
+	limit   int64
+	window  time.Duration
+}
+
+func NewRateLimiter(backend RedisBackend, name, prefix string, limit int64, window time.Duration) *RateLimiter {
+	return &RateLimiter{backend: backend, name: name, prefix: prefix, limit: limit, window: window}
+}
+
+// Allow increments key's counter for the current window and reports whether
+// the caller is still under the limit.
+func (rl *RateLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	fullKey := fmt.Sprintf("%s%s", rl.prefix, key)
+
+	count, err := rl.backend.Incr(ctx, fullKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	if count == 1 {
+		if err := rl.backend.Expire(ctx, fullKey, rl.window).Err(); err != nil {
+			return false, err
+		}
+	}
+
+	allowed := count <= rl.limit
+	if allowed {
+		rateLimitEventsTotal.WithLabelValues(rl.name, "allowed").Inc()
+	} else {
+		rateLimitEventsTotal.WithLabelValues(rl.name, "denied").Inc()
+	}
+
+	return allowed, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DriverUpdatesChannel = "driver_updates" // Pub/Sub channel for driver location changes
+
+	streamClientBufferSize = 64 // bounded per-client event buffer (drop-oldest on overflow)
+	streamPingInterval     = 30 * time.Second
+	streamPongWait         = 60 * time.Second
+	streamWriteWait        = 10 * time.Second
+)
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// DriverUpdateEvent is published on DriverUpdatesChannel whenever a driver's
+// location changes or a driver's session expires.
+type DriverUpdateEvent struct {
+	DriverID  string  `json:"driverId"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	IsActive  bool    `json:"isActive"`
+	Left      bool    `json:"left"` // true when the driver expired/went offline
+}
+
+// streamQuery is the initial message a client sends right after the
+// WebSocket handshake to establish its nearby-driver viewport.
+type streamQuery struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Radius    float64 `json:"radius"` // in kilometers
+	Limit     int     `json:"limit"`
+}
+
+// streamEvent is what's sent down the socket to the client.
+type streamEvent struct {
+	Type      string  `json:"type"` // "enter", "move", or "leave"
+	DriverID  string  `json:"driverId"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+	Distance  float64 `json:"distance,omitempty"` // in kilometers
+}
+
+func (app *App) publishDriverUpdate(ctx context.Context, event DriverUpdateEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling driver update event: %v", err)
+		return
+	}
+
+	if err := app.RedisCache.Publish(ctx, DriverUpdatesChannel, payload).Err(); err != nil {
+		log.Printf("Error publishing driver update: %v", err)
+	}
+}
+
+// NearbyDriversStream upgrades the connection to a WebSocket, sends an
+// initial snapshot of drivers already inside the client's query radius as
+// enter events, then continuously pushes enter/move/leave events derived
+// from the driver_updates Pub/Sub feed.
+func (app *App) NearbyDriversStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(streamPongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(streamPongWait))
+		return nil
+	})
+
+	var query streamQuery
+	if err := conn.ReadJSON(&query); err != nil {
+		log.Printf("Error reading stream query: %v", err)
+		return
+	}
+	if query.Radius == 0 {
+		query.Radius = 5
+	}
+	if query.Limit == 0 {
+		query.Limit = 10
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	pubsub := app.RedisCache.PSubscribe(ctx, DriverUpdatesChannel)
+	defer pubsub.Close()
+
+	events := make(chan streamEvent, streamClientBufferSize)
+	var nearby sync.Map // driverID -> bool (currently inside radius)
+
+	initial, err := app.geoSearchNearby(ctx, query.Latitude, query.Longitude, query.Radius, query.Limit)
+	if err != nil {
+		log.Printf("Error loading initial nearby snapshot: %v", err)
+	}
+	for _, loc := range initial {
+		nearby.Store(loc.Name, true)
+		ev := streamEvent{Type: "enter", DriverID: loc.Name, Latitude: loc.Latitude, Longitude: loc.Longitude, Distance: loc.Dist}
+		conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		if err := conn.WriteJSON(ev); err != nil {
+			return
+		}
+	}
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var update DriverUpdateEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &update); err != nil {
+				continue
+			}
+
+			wasNear, _ := nearby.Load(update.DriverID)
+			dist := haversineKm(query.Latitude, query.Longitude, update.Latitude, update.Longitude)
+			isNear := !update.Left && dist <= query.Radius
+
+			var ev streamEvent
+			switch {
+			case isNear && wasNear != true:
+				ev = streamEvent{Type: "enter", DriverID: update.DriverID, Latitude: update.Latitude, Longitude: update.Longitude, Distance: dist}
+				nearby.Store(update.DriverID, true)
+			case isNear && wasNear == true:
+				ev = streamEvent{Type: "move", DriverID: update.DriverID, Latitude: update.Latitude, Longitude: update.Longitude, Distance: dist}
+			case !isNear && wasNear == true:
+				ev = streamEvent{Type: "leave", DriverID: update.DriverID}
+				nearby.Delete(update.DriverID)
+			default:
+				continue
+			}
+
+			select {
+			case events <- ev:
+			default:
+				// Backpressure: drop the oldest queued event in favor of the new one.
+				select {
+				case <-events:
+				default:
+				}
+				select {
+				case events <- ev:
+				default:
+				}
+			}
+		}
+	}()
+
+	go app.readStreamPump(conn, cancel)
+
+	ticker := time.NewTicker(streamPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteJSON(ev); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readStreamPump drains and discards client messages so control frames
+// (pong, close) are processed, cancelling ctx once the client disconnects.
+func (app *App) readStreamPump(conn *websocket.Conn, cancel context.CancelFunc) {
+	defer cancel()
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// haversineKm returns the great-circle distance between two points in kilometers.
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := rad(lat2 - lat1)
+	dLon := rad(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+
+	return earthRadiusKm * c
+}